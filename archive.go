@@ -0,0 +1,534 @@
+package fnspath
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat selects the archive container ArchiveDir/ExtractArchive
+// use. ArchiveFormatAuto detects it from the archive's file name.
+type ArchiveFormat int
+
+const (
+	ArchiveFormatAuto ArchiveFormat = iota
+	ArchiveFormatTar
+	ArchiveFormatTarGz
+	ArchiveFormatTarZst
+	ArchiveFormatZip
+)
+
+// detectArchiveFormat auto-detects a format from name's suffix alone;
+// ok is false when the suffix isn't recognized.
+func detectArchiveFormat(name string) (format ArchiveFormat, ok bool) {
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveFormatTarGz, true
+	case strings.HasSuffix(name, ".tar.zst"):
+		return ArchiveFormatTarZst, true
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveFormatZip, true
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveFormatTar, true
+	default:
+		return ArchiveFormatAuto, false
+	}
+}
+
+// gzipMagic, zstdMagic and zipMagic are the leading bytes that
+// identify each container, used by detectArchiveFormatFile when name
+// has no (or an unrecognized) extension.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte{'P', 'K'}
+)
+
+const tarMagicOffset = 257 // ustar magic lives at this offset in a tar header block
+
+// detectArchiveFormatFile auto-detects srcArchive's format, preferring
+// name's suffix and falling back to sniffing its leading magic bytes
+// (gzip/zstd/zip headers, or the ustar magic inside a tar header)
+// when the suffix is missing or unrecognized.
+func detectArchiveFormatFile(fs Fs, srcArchive string) (ArchiveFormat, error) {
+	if format, ok := detectArchiveFormat(srcArchive); ok {
+		return format, nil
+	}
+
+	f, err := fs.Open(srcArchive)
+	if err != nil {
+		return ArchiveFormatAuto, err
+	}
+	defer f.Close()
+
+	head := make([]byte, tarMagicOffset+5)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return ArchiveFormatTarGz, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		return ArchiveFormatTarZst, nil
+	case bytes.HasPrefix(head, zipMagic):
+		return ArchiveFormatZip, nil
+	case len(head) >= tarMagicOffset+5 && string(head[tarMagicOffset:tarMagicOffset+5]) == "ustar":
+		return ArchiveFormatTar, nil
+	default:
+		return ArchiveFormatAuto, fmt.Errorf("fnspath: cannot detect archive format of %q from name or magic bytes", srcArchive)
+	}
+}
+
+// ArchiveOptions tunes ArchiveDir. Currently empty, reserved for
+// future knobs (compression level, ownership, ...).
+type ArchiveOptions struct{}
+
+// ExtractOptions tunes ExtractArchive. Only regular files and
+// directories are extracted — symlinks, hardlinks and other special
+// tar entry types are skipped, because the Fs interface has no
+// Symlink/Mknod equivalent to recreate them; each skip is reported
+// through OnSkippedEntry instead of being logged.
+type ExtractOptions struct {
+	Format ArchiveFormat
+
+	// PreserveMode applies each entry's stored file mode to the
+	// extracted file/directory. When false, fixed defaults (0o755
+	// dirs / 0o644 files) are used instead.
+	PreserveMode bool
+
+	// MaxSize caps the total number of decompressed bytes written,
+	// guarding against zip/tar bombs. 0 means unlimited.
+	MaxSize int64
+
+	// OnSkippedEntry, when set, is called for every tar entry that
+	// isn't extracted (see the type doc above), naming it and its
+	// tar.TypeFlag. Left nil, skips aren't reported anywhere.
+	OnSkippedEntry func(name string, typeflag byte)
+}
+
+// ArchiveDirWithFs packs srcDir into dstArchive using fs, in the given
+// format (or auto-detected from dstArchive's name when format is
+// ArchiveFormatAuto).
+func ArchiveDirWithFs(fs Fs, srcDir, dstArchive string, format ArchiveFormat, opts ArchiveOptions) error {
+	if format == ArchiveFormatAuto {
+		detected, ok := detectArchiveFormat(dstArchive)
+		if !ok {
+			return fmt.Errorf("fnspath: cannot auto-detect archive format from %q", dstArchive)
+		}
+
+		format = detected
+	}
+
+	out, err := fs.Create(dstArchive)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch format {
+	case ArchiveFormatZip:
+		return archiveDirZip(fs, srcDir, out)
+	case ArchiveFormatTarGz:
+		gw := gzip.NewWriter(out)
+		defer gw.Close()
+
+		return archiveDirTar(fs, srcDir, gw)
+	case ArchiveFormatTarZst:
+		zw, err := zstd.NewWriter(out)
+		if err != nil {
+			return err
+		}
+		defer zw.Close()
+
+		return archiveDirTar(fs, srcDir, zw)
+	case ArchiveFormatTar:
+		return archiveDirTar(fs, srcDir, out)
+	default:
+		return fmt.Errorf("fnspath: unsupported archive format %d", format)
+	}
+}
+
+// ArchiveDir packs srcDir into dstArchive on disk.
+func ArchiveDir(srcDir, dstArchive string, format ArchiveFormat, opts ArchiveOptions) error {
+	return ArchiveDirWithFs(DefaultFs, srcDir, dstArchive, format, opts)
+}
+
+func archiveDirTar(fs Fs, srcDir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	return walkFs(fs, srcDir, func(path string, fi os.FileInfo) error {
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			return nil
+		}
+
+		f, err := fs.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+}
+
+func archiveDirZip(fs Fs, srcDir string, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	return walkFs(fs, srcDir, func(path string, fi os.FileInfo) error {
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		name := filepath.ToSlash(rel)
+		if fi.IsDir() {
+			name += "/"
+		}
+
+		hdr, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Method = zip.Deflate
+
+		entry, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			return nil
+		}
+
+		f, err := fs.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(entry, f)
+
+		return err
+	})
+}
+
+// walkFs walks root (depth-first, parent before children), calling fn
+// for every entry including root itself.
+func walkFs(fs Fs, root string, fn func(path string, fi os.FileInfo) error) error {
+	fi, err := fs.Stat(root)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(root, fi); err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return nil
+	}
+
+	entries, err := fs.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := walkFs(fs, filepath.Join(root, entry.Name()), fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExtractArchiveWithFs unpacks srcArchive into dstDir using fs. Entries
+// whose cleaned path would escape dstDir are rejected (Zip Slip guard).
+func ExtractArchiveWithFs(fs Fs, srcArchive, dstDir string, opts ExtractOptions) error {
+	format := opts.Format
+	if format == ArchiveFormatAuto {
+		detected, err := detectArchiveFormatFile(fs, srcArchive)
+		if err != nil {
+			return err
+		}
+
+		format = detected
+	}
+
+	if err := MkdirAllWithFs(fs, dstDir, 0o755); err != nil {
+		return err
+	}
+
+	switch format {
+	case ArchiveFormatZip:
+		return extractZip(fs, srcArchive, dstDir, opts)
+	case ArchiveFormatTarGz, ArchiveFormatTarZst, ArchiveFormatTar:
+		return extractTar(fs, srcArchive, dstDir, format, opts)
+	default:
+		return fmt.Errorf("fnspath: unsupported archive format %d", format)
+	}
+}
+
+// ExtractArchive unpacks srcArchive (on disk) into dstDir.
+func ExtractArchive(srcArchive, dstDir string, opts ExtractOptions) error {
+	return ExtractArchiveWithFs(DefaultFs, srcArchive, dstDir, opts)
+}
+
+func extractTar(fs Fs, srcArchive, dstDir string, format ArchiveFormat, opts ExtractOptions) error {
+	f, err := fs.Open(srcArchive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+
+	switch format {
+	case ArchiveFormatTarGz:
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+
+		r = gr
+	case ArchiveFormatTarZst:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+
+	var written int64
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		dstPath, err := safeJoin(dstDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(0o644)
+		if opts.PreserveMode {
+			mode = hdr.FileInfo().Mode()
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			dirMode := os.FileMode(0o755)
+			if opts.PreserveMode {
+				dirMode = mode
+			}
+
+			if err := MkdirAllWithFs(fs, dstPath, dirMode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := MkdirAllWithFs(fs, filepath.Dir(dstPath), 0o755); err != nil {
+				return err
+			}
+
+			written, err = extractFileWithFs(fs, dstPath, mode, tr, hdr.Size, opts.MaxSize, written)
+			if err != nil {
+				return err
+			}
+		default:
+			// Symlinks, hardlinks, devices, fifos, ... aren't
+			// represented by the Fs interface (no Symlink/Mknod), so
+			// they're skipped rather than extracted; report that to
+			// the caller instead of dropping the entry silently.
+			if opts.OnSkippedEntry != nil {
+				opts.OnSkippedEntry(hdr.Name, hdr.Typeflag)
+			}
+		}
+	}
+}
+
+func extractZip(fs Fs, srcArchive, dstDir string, opts ExtractOptions) error {
+	// archive/zip needs an io.ReaderAt; the real OS filesystem gives
+	// us one for free via *os.File, other backends (e.g. MemFs) are
+	// read fully into memory first.
+	var ra io.ReaderAt
+	var size int64
+
+	if osFs, ok := fs.(*OsFs); ok {
+		_ = osFs
+
+		f, err := os.Open(srcArchive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		ra, size = f, fi.Size()
+	} else {
+		f, err := fs.Open(srcArchive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return err
+	}
+
+	var written int64
+
+	for _, entry := range zr.File {
+		dstPath, err := safeJoin(dstDir, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(0o644)
+		if opts.PreserveMode {
+			mode = entry.Mode()
+		}
+
+		if entry.FileInfo().IsDir() {
+			dirMode := os.FileMode(0o755)
+			if opts.PreserveMode {
+				dirMode = mode
+			}
+
+			if err := MkdirAllWithFs(fs, dstPath, dirMode); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := MkdirAllWithFs(fs, filepath.Dir(dstPath), 0o755); err != nil {
+			return err
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+
+		written, err = extractFileWithFs(fs, dstPath, mode, rc, int64(entry.UncompressedSize64), opts.MaxSize, written)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFileWithFs(fs Fs, dstPath string, mode os.FileMode, r io.Reader, entrySize, maxSize, written int64) (int64, error) {
+	if maxSize > 0 && written+entrySize > maxSize {
+		return written, fmt.Errorf("fnspath: extracted size exceeds MaxSize (%d bytes)", maxSize)
+	}
+
+	out, err := fs.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return written, err
+	}
+	defer out.Close()
+
+	limited := r
+	if maxSize > 0 {
+		limited = io.LimitReader(r, maxSize-written+1)
+	}
+
+	n, err := io.Copy(out, limited)
+	if err != nil {
+		return written, err
+	}
+
+	written += n
+
+	if maxSize > 0 && written > maxSize {
+		return written, fmt.Errorf("fnspath: extracted size exceeds MaxSize (%d bytes)", maxSize)
+	}
+
+	return written, nil
+}
+
+// safeJoin joins dstDir with the archive-provided name, rejecting
+// (not silently clamping) any entry whose path would escape dstDir,
+// via ".." components or an absolute path (Zip Slip).
+func safeJoin(dstDir, name string) (string, error) {
+	if filepath.IsAbs(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("fnspath: archive entry %q escapes destination", name)
+	}
+
+	joined := filepath.Join(dstDir, filepath.FromSlash(name))
+
+	rel, err := filepath.Rel(dstDir, joined)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("fnspath: archive entry %q escapes destination", name)
+	}
+
+	return joined, nil
+}
@@ -0,0 +1,100 @@
+package fnspath
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+)
+
+func TestArchiveDirExtractArchiveRoundTrip(t *testing.T) {
+	fs := NewMemFs()
+
+	if err := ToFileWithFs(fs, "/src/a.txt", 0o644, bytes.NewBufferString("hello")); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+	if err := ToFileWithFs(fs, "/src/nested/b.txt", 0o644, bytes.NewBufferString("world")); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+
+	if err := ArchiveDirWithFs(fs, "/src", "/out.tar", ArchiveFormatTar, ArchiveOptions{}); err != nil {
+		t.Fatalf("ArchiveDirWithFs: %v", err)
+	}
+
+	if err := ExtractArchiveWithFs(fs, "/out.tar", "/dst", ExtractOptions{Format: ArchiveFormatTar}); err != nil {
+		t.Fatalf("ExtractArchiveWithFs: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"/dst/a.txt":        "hello",
+		"/dst/nested/b.txt": "world",
+	} {
+		f, err := fs.Open(path)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", path, err)
+		}
+
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(f); err != nil {
+			t.Fatalf("ReadFrom(%q): %v", path, err)
+		}
+		f.Close()
+
+		if buf.String() != want {
+			t.Fatalf("%s: got %q, want %q", path, buf.String(), want)
+		}
+	}
+}
+
+func TestExtractArchiveRejectsZipSlip(t *testing.T) {
+	fs := NewMemFs()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape.txt", Mode: 0o644, Size: int64(len("gotcha"))}); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	if _, err := tw.Write([]byte("gotcha")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := ToFileWithFs(fs, "/evil.tar", 0o644, &buf); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+
+	if err := ExtractArchiveWithFs(fs, "/evil.tar", "/dst", ExtractOptions{Format: ArchiveFormatTar}); err == nil {
+		t.Fatalf("expected ExtractArchiveWithFs to reject a Zip Slip entry")
+	}
+
+	if _, err := fs.Stat("/escape.txt"); err == nil {
+		t.Fatalf("escape.txt should not have been written outside dstDir")
+	}
+}
+
+func TestExtractArchiveEnforcesMaxSize(t *testing.T) {
+	fs := NewMemFs()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	payload := bytes.Repeat([]byte("x"), 1024)
+	if err := tw.WriteHeader(&tar.Header{Name: "big.txt", Mode: 0o644, Size: int64(len(payload))}); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	if _, err := tw.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := ToFileWithFs(fs, "/big.tar", 0o644, &buf); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+
+	err := ExtractArchiveWithFs(fs, "/big.tar", "/dst", ExtractOptions{Format: ArchiveFormatTar, MaxSize: 128})
+	if err == nil {
+		t.Fatalf("expected ExtractArchiveWithFs to reject an archive exceeding MaxSize")
+	}
+}
@@ -0,0 +1,144 @@
+package fnspath
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func tempName(dst string) string {
+	return fmt.Sprintf("%s.tmp-%d-%d", dst, os.Getpid(), time.Now().UnixNano())
+}
+
+// AtomicWriter stages writes in a temp file next to the destination
+// and only publishes them on Commit(); Abort() discards the temp file
+// instead. This is the temp-file + fsync + rename pattern restic and
+// other backup tools use to avoid corrupted files after a crash.
+type AtomicWriter struct {
+	fs   Fs
+	dst  string
+	tmp  string
+	f    File
+	done bool
+}
+
+// NewAtomicWriterWithFs creates the backing temp file for dst, using fs.
+func NewAtomicWriterWithFs(fs Fs, dst string, mode os.FileMode) (*AtomicWriter, error) {
+	tmp := tempName(dst)
+
+	f, err := fs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AtomicWriter{fs: fs, dst: dst, tmp: tmp, f: f}, nil
+}
+
+func NewAtomicWriter(dst string, mode os.FileMode) (*AtomicWriter, error) {
+	return NewAtomicWriterWithFs(DefaultFs, dst, mode)
+}
+
+func (aw *AtomicWriter) Write(p []byte) (int, error) { return aw.f.Write(p) }
+
+// Commit fsyncs the temp file, closes it, renames it over dst, and
+// finally fsyncs dst's parent directory so the rename itself survives
+// a crash.
+func (aw *AtomicWriter) Commit() error {
+	if aw.done {
+		return nil
+	}
+	aw.done = true
+
+	if syncer, ok := aw.f.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			aw.f.Close()
+			aw.fs.Remove(aw.tmp)
+			return err
+		}
+	}
+
+	if err := aw.f.Close(); err != nil {
+		aw.fs.Remove(aw.tmp)
+		return err
+	}
+
+	if err := MVWithFs(aw.fs, aw.tmp, aw.dst); err != nil {
+		aw.fs.Remove(aw.tmp)
+		return err
+	}
+
+	return fsyncDir(aw.fs, filepath.Dir(aw.dst))
+}
+
+// Abort discards the temp file without touching dst.
+func (aw *AtomicWriter) Abort() error {
+	if aw.done {
+		return nil
+	}
+	aw.done = true
+
+	aw.f.Close()
+
+	return aw.fs.Remove(aw.tmp)
+}
+
+func fsyncDir(fs Fs, dir string) error {
+	f, err := fs.Open(dir)
+	if err != nil {
+		// Best-effort: a directory that can't be opened is skipped,
+		// as is one whose File has no Sync method (e.g. MemFs's).
+		return nil
+	}
+	defer f.Close()
+
+	if syncer, ok := f.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+
+	return nil
+}
+
+func WriteFileAtomicWithFs(fs Fs, path string, dirMode, fileMode os.FileMode, src io.Reader) (int64, error) {
+	dir := filepath.Dir(path)
+	if e := EnsureWithFs(fs, dir, dirMode); e != nil {
+		return 0, e
+	}
+
+	aw, err := NewAtomicWriterWithFs(fs, path, fileMode)
+	if err != nil {
+		return 0, err
+	}
+
+	sz, err := io.Copy(aw, src)
+	if err != nil {
+		aw.Abort()
+		return 0, err
+	}
+
+	if err := aw.Commit(); err != nil {
+		return 0, err
+	}
+
+	return sz, nil
+}
+
+func WriteFileAtomic(path string, dirMode, fileMode os.FileMode, src io.Reader) (int64, error) {
+	return WriteFileAtomicWithFs(DefaultFs, path, dirMode, fileMode, src)
+}
+
+func ToFileAtomicWithFs(fs Fs, path string, mode os.FileMode, reader io.Reader) error {
+	dir := filepath.Dir(path)
+	if e := EnsureWithFs(fs, dir, mode); e != nil {
+		return e
+	}
+
+	_, err := WriteFileAtomicWithFs(fs, path, mode, mode, reader)
+
+	return err
+}
+
+func ToFileAtomic(path string, mode os.FileMode, reader io.Reader) error {
+	return ToFileAtomicWithFs(DefaultFs, path, mode, reader)
+}
@@ -0,0 +1,78 @@
+package fnspath
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteFileAtomicCommitPublishesOnSuccess(t *testing.T) {
+	fs := NewMemFs()
+
+	if _, err := WriteFileAtomicWithFs(fs, "/out.txt", 0o755, 0o644, bytes.NewBufferString("v1")); err != nil {
+		t.Fatalf("WriteFileAtomicWithFs: %v", err)
+	}
+
+	d, err := Checksum("/out.txt", SHA256Algo, WithFs(fs), WithBuffer())
+	if err != nil {
+		t.Fatalf("Checksum: %v", err)
+	}
+	if string(d.B.Bytes()) != "v1" {
+		t.Fatalf("got %q, want %q", d.B.Bytes(), "v1")
+	}
+
+	entries, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name() != "out.txt" {
+			t.Fatalf("leftover temp entry after commit: %q", e.Name())
+		}
+	}
+}
+
+func TestAtomicWriterAbortLeavesDestinationUntouched(t *testing.T) {
+	fs := NewMemFs()
+
+	if err := ToFileWithFs(fs, "/out.txt", 0o644, bytes.NewBufferString("original")); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+
+	aw, err := NewAtomicWriterWithFs(fs, "/out.txt", 0o644)
+	if err != nil {
+		t.Fatalf("NewAtomicWriterWithFs: %v", err)
+	}
+
+	if _, err := aw.Write([]byte("corrupted")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if err := aw.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+
+	f, err := fs.Open("/out.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(f); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+
+	if buf.String() != "original" {
+		t.Fatalf("destination changed after Abort: got %q, want %q", buf.String(), "original")
+	}
+
+	entries, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name() != "out.txt" {
+			t.Fatalf("leftover temp entry after abort: %q", e.Name())
+		}
+	}
+}
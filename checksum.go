@@ -0,0 +1,293 @@
+package fnspath
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-x-pkg/bufpool"
+	"lukechampine.com/blake3"
+)
+
+// Algo selects the hash algorithm a Checksum call uses.
+type Algo int
+
+const (
+	MD5Algo Algo = iota
+	SHA1Algo
+	SHA256Algo
+	BLAKE3Algo
+)
+
+func (a Algo) String() string {
+	switch a {
+	case MD5Algo:
+		return "md5"
+	case SHA1Algo:
+		return "sha1"
+	case SHA256Algo:
+		return "sha256"
+	case BLAKE3Algo:
+		return "blake3"
+	default:
+		return "unknown"
+	}
+}
+
+func (a Algo) new() (hash.Hash, error) {
+	switch a {
+	case MD5Algo:
+		return md5.New(), nil
+	case SHA1Algo:
+		return sha1.New(), nil
+	case SHA256Algo:
+		return sha256.New(), nil
+	case BLAKE3Algo:
+		return blake3.New(32, nil), nil
+	default:
+		return nil, fmt.Errorf("fnspath: unknown checksum algo %d", a)
+	}
+}
+
+// Digest is the result of a Checksum call.
+type Digest struct {
+	Path    string
+	Algo    Algo
+	Sum     []byte
+	Latency time.Duration // calculation latency
+	Sz      uint64
+	B       *bufpool.Buf // only set when WithBuffer() is passed
+}
+
+func (d Digest) Release() {
+	if d.B != nil {
+		d.B.Release()
+	}
+}
+
+type options struct {
+	fs         Fs
+	withBuffer bool
+}
+
+// Option configures a Checksum/ChecksumWildcard call.
+type Option func(*options)
+
+// WithBuffer captures the file contents into a Digest.B as they're
+// streamed through the hash, at the cost of the memory the old MD5.Do
+// always paid. Large-file callers should leave this off.
+func WithBuffer() Option { return func(o *options) { o.withBuffer = true } }
+
+// WithFs overrides the Fs a Checksum/ChecksumWildcard call uses.
+func WithFs(fs Fs) Option { return func(o *options) { o.fs = fs } }
+
+func buildOptions(opts []Option) options {
+	o := options{fs: DefaultFs}
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	return o
+}
+
+// Checksum streams path through algo, without loading the whole file
+// into memory (unlike the old MD5.Do, which read the file fully
+// before hashing it).
+func Checksum(path string, algo Algo, opts ...Option) (Digest, error) {
+	o := buildOptions(opts)
+
+	start := time.Now()
+
+	fi, err := o.fs.Stat(path)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	f, err := o.fs.Open(path)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer f.Close()
+
+	h, err := algo.new()
+	if err != nil {
+		return Digest{}, err
+	}
+
+	var w io.Writer = h
+
+	var buf *bufpool.Buf
+	if o.withBuffer {
+		buf = bufpool.NewBuf()
+		w = io.MultiWriter(h, buf)
+	}
+
+	if _, err := io.Copy(w, f); err != nil {
+		return Digest{}, err
+	}
+
+	return Digest{
+		Path:    path,
+		Algo:    algo,
+		Sum:     h.Sum(nil),
+		Latency: time.Since(start),
+		Sz:      uint64(fi.Size()),
+		B:       buf,
+	}, nil
+}
+
+// ChecksumWildcard expands pattern against opts' Fs (so a WithFs(...)
+// virtual filesystem is matched too, not just the real OS one),
+// checksums every match with algo, and returns the per-file digests
+// sorted by path; pass them to RootDigest to fold them into a single
+// stable root digest — the same wildcard-checksum contract BuildKit
+// uses for COPY --from. When followLinks is false, symlinks among the
+// matches are skipped rather than dereferenced.
+func ChecksumWildcard(pattern string, algo Algo, followLinks bool, opts ...Option) ([]Digest, error) {
+	o := buildOptions(opts)
+
+	matches, err := fsGlob(o.fs, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+
+	digests := make([]Digest, 0, len(matches))
+
+	for _, m := range matches {
+		fi, err := o.fs.Lstat(m)
+		if err != nil {
+			return nil, err
+		}
+
+		if !followLinks && fi.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		if followLinks {
+			if fi, err = o.fs.Stat(m); err != nil {
+				return nil, err
+			}
+		}
+
+		if fi.IsDir() {
+			continue
+		}
+
+		d, err := Checksum(m, algo, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		digests = append(digests, d)
+	}
+
+	return digests, nil
+}
+
+// fsGlob is filepath.Glob's algorithm (split into a meta-free
+// directory prefix, then match the final component), rewired to walk
+// fs instead of the real OS filesystem, so a virtual/in-memory Fs
+// passed via WithFs is honoured too.
+func fsGlob(fs Fs, pattern string) ([]string, error) {
+	if !globHasMeta(pattern) {
+		if _, err := fs.Lstat(pattern); err != nil {
+			return nil, nil
+		}
+
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = globCleanDir(dir)
+
+	if !globHasMeta(dir) {
+		return globDir(fs, dir, file, nil)
+	}
+
+	dirs, err := fsGlob(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, d := range dirs {
+		if matches, err = globDir(fs, d, file, matches); err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
+
+func globCleanDir(path string) string {
+	switch path {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return path
+	default:
+		return path[:len(path)-1] // chop trailing separator
+	}
+}
+
+func globHasMeta(path string) bool { return strings.ContainsAny(path, "*?[") }
+
+func globDir(fs Fs, dir, pattern string, matches []string) ([]string, error) {
+	fi, err := fs.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return matches, nil
+	}
+
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return matches, nil
+	}
+
+	for _, e := range entries {
+		matched, err := filepath.Match(pattern, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			matches = append(matches, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return matches, nil
+}
+
+// RootDigest folds digests (as returned by ChecksumWildcard) into a
+// single stable digest by hashing algo(name) || algo(content) pairs,
+// independent of filesystem iteration order: digests must already be
+// sorted by Path.
+func RootDigest(digests []Digest, algo Algo) ([]byte, error) {
+	h, err := algo.new()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range digests {
+		nh, err := algo.new()
+		if err != nil {
+			return nil, err
+		}
+		nh.Write([]byte(d.Path))
+		h.Write(nh.Sum(nil))
+
+		// d.Sum is already algo(content).
+		h.Write(d.Sum)
+	}
+
+	return h.Sum(nil), nil
+}
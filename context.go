@@ -0,0 +1,249 @@
+package fnspath
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const defaultCopyChunkSize = 1 << 20 // 1 MiB
+
+// CopyOptions tunes the context-aware copy variants (CopyFileContext,
+// PathCopyDirContext, WriteFileContext).
+type CopyOptions struct {
+	// ChunkSize controls how often ctx.Err() is checked, and how often
+	// Progress is invoked. Defaults to 1 MiB.
+	ChunkSize int
+
+	// Progress, when set, is called after every chunk with the total
+	// bytes copied so far and the total size (0 if unknown).
+	Progress func(bytesCopied, bytesTotal int64)
+}
+
+func (o CopyOptions) chunkSize() int {
+	if o.ChunkSize > 0 {
+		return o.ChunkSize
+	}
+
+	return defaultCopyChunkSize
+}
+
+// ctxWriter wraps an io.Writer, aborting with ctx.Err() between writes
+// and reporting progress through opts.Progress, so a long io.Copy can
+// be cancelled and observed mid-flight.
+type ctxWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	opts    CopyOptions
+	total   int64
+	written int64
+}
+
+func (cw *ctxWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.written += int64(n)
+
+	if cw.opts.Progress != nil {
+		cw.opts.Progress(cw.written, cw.total)
+	}
+
+	return n, err
+}
+
+func copyWithContext(ctx context.Context, dst io.Writer, src io.Reader, total int64, opts CopyOptions) (int64, error) {
+	cw := &ctxWriter{ctx: ctx, w: dst, opts: opts, total: total}
+	buf := make([]byte, opts.chunkSize())
+
+	// Hide any io.WriterTo on src so io.CopyBuffer really copies in
+	// ChunkSize pieces instead of handing src the whole writer.
+	return io.CopyBuffer(cw, struct{ io.Reader }{src}, buf)
+}
+
+func CopyFileContextWithFs(ctx context.Context, fs Fs, source, dest string, mode os.FileMode, opts CopyOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sourcefile, err := fs.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourcefile.Close()
+
+	var total int64
+	if fi, err := fs.Stat(source); err == nil {
+		total = fi.Size()
+	}
+
+	destfile, err := fs.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer destfile.Close()
+
+	if _, err := copyWithContext(ctx, destfile, sourcefile, total, opts); err != nil {
+		return err
+	}
+
+	if mode != 0 {
+		return fs.Chmod(dest, mode)
+	}
+
+	sourceinfo, err := fs.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	return fs.Chmod(dest, sourceinfo.Mode())
+}
+
+func CopyFileContext(ctx context.Context, source, dest string, mode os.FileMode, opts CopyOptions) error {
+	return CopyFileContextWithFs(ctx, DefaultFs, source, dest, mode, opts)
+}
+
+func PathCopyDirContextWithFs(ctx context.Context, fs Fs, source, dest string, opts CopyOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sourceinfo, err := fs.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if err := MkdirAllWithFs(fs, dest, sourceinfo.Mode()); err != nil {
+		return err
+	}
+
+	directory, err := fs.Open(source)
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
+
+	objects, err := directory.Readdir(-1)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		sourcefilepointer := filepath.Join(source, obj.Name())
+		destinationfilepointer := filepath.Join(dest, obj.Name())
+
+		if obj.IsDir() {
+			if err := PathCopyDirContextWithFs(ctx, fs, sourcefilepointer, destinationfilepointer, opts); err != nil {
+				return err
+			}
+		} else if err := CopyFileContextWithFs(ctx, fs, sourcefilepointer, destinationfilepointer, 0, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func PathCopyDirContext(ctx context.Context, source, dest string, opts CopyOptions) error {
+	return PathCopyDirContextWithFs(ctx, DefaultFs, source, dest, opts)
+}
+
+func RemoveContextWithFs(ctx context.Context, fs Fs, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fi, err := fs.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !fi.IsDir() {
+		return RemoveWithFs(fs, path)
+	}
+
+	dir, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+
+	entries, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := RemoveContextWithFs(ctx, fs, filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return RemoveWithFs(fs, path)
+}
+
+func RemoveContext(ctx context.Context, path string) error {
+	return RemoveContextWithFs(ctx, DefaultFs, path)
+}
+
+func WriteFileContextWithFs(ctx context.Context, fs Fs, path string, dirMode, fileMode os.FileMode, src io.Reader, opts CopyOptions) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Dir(path)
+	if e := EnsureWithFs(fs, dir, dirMode); e != nil {
+		return 0, e
+	}
+
+	f, e := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if e != nil {
+		return 0, e
+	}
+	defer f.Close()
+
+	return copyWithContext(ctx, f, src, 0, opts)
+}
+
+func WriteFileContext(ctx context.Context, path string, dirMode, fileMode os.FileMode, src io.Reader, opts CopyOptions) (int64, error) {
+	return WriteFileContextWithFs(ctx, DefaultFs, path, dirMode, fileMode, src, opts)
+}
+
+func MVContextWithFs(ctx context.Context, fs Fs, oldpath, newpath string) (e error) {
+	attempt := MvAttempts
+
+	for attempt > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		e = fs.Rename(oldpath, newpath)
+		if e != nil {
+			attempt--
+			continue
+		}
+
+		return nil
+	}
+
+	return e
+}
+
+func MVContext(ctx context.Context, oldpath, newpath string) (e error) {
+	return MVContextWithFs(ctx, DefaultFs, oldpath, newpath)
+}
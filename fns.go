@@ -3,7 +3,6 @@ package fnspath
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -14,8 +13,8 @@ const (
 	MvAttempts     int = 20
 )
 
-func IsExists(path string) (bool, error) {
-	if _, e := os.Stat(path); e != nil {
+func IsExistsWithFs(fs Fs, path string) (bool, error) {
+	if _, e := fs.Stat(path); e != nil {
 		if os.IsNotExist(e) {
 			return false, nil
 		}
@@ -25,15 +24,17 @@ func IsExists(path string) (bool, error) {
 	return true, nil
 }
 
-func MkdirAll(path string, perm os.FileMode) error {
+func IsExists(path string) (bool, error) { return IsExistsWithFs(DefaultFs, path) }
+
+func MkdirAllWithFs(fs Fs, path string, perm os.FileMode) error {
 	// Fast path: if we can tell whether path is a directory or file, stop with success or error.
-	dir, err := os.Stat(path)
+	dir, err := fs.Stat(path)
 	if err == nil {
 		if dir.IsDir() {
 			return nil
 		}
 
-		return &os.PathError{"mkdir", path, syscall.ENOTDIR}
+		return &os.PathError{Op: "mkdir", Path: path, Err: syscall.ENOTDIR}
 	}
 
 	// Slow path: make sure parent exists and then call Mkdir for path.
@@ -49,18 +50,18 @@ func MkdirAll(path string, perm os.FileMode) error {
 
 	if j > 1 {
 		// Create parent
-		err = MkdirAll(path[0:j-1], perm)
+		err = MkdirAllWithFs(fs, path[0:j-1], perm)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Parent now exists; invoke Mkdir and use its result.
-	err = os.Mkdir(path, perm)
+	err = fs.Mkdir(path, perm)
 	if err != nil {
 		// Handle arguments like "foo/." by
 		// double-checking that directory doesn't exist.
-		dir, err1 := os.Lstat(path)
+		dir, err1 := fs.Lstat(path)
 		if err1 == nil && dir.IsDir() {
 			return nil
 		}
@@ -68,7 +69,7 @@ func MkdirAll(path string, perm os.FileMode) error {
 		return err
 	}
 
-	err = os.Chmod(path, perm)
+	err = fs.Chmod(path, perm)
 	if err != nil {
 		return err
 	}
@@ -76,11 +77,13 @@ func MkdirAll(path string, perm os.FileMode) error {
 	return nil
 }
 
-func Ensure(path string, perm os.FileMode) error {
-	if ok, e := IsExists(path); e != nil {
+func MkdirAll(path string, perm os.FileMode) error { return MkdirAllWithFs(DefaultFs, path, perm) }
+
+func EnsureWithFs(fs Fs, path string, perm os.FileMode) error {
+	if ok, e := IsExistsWithFs(fs, path); e != nil {
 		return e
 	} else if !ok {
-		if e = MkdirAll(path, perm); e != nil {
+		if e = MkdirAllWithFs(fs, path, perm); e != nil {
 			return e
 		}
 	}
@@ -88,11 +91,13 @@ func Ensure(path string, perm os.FileMode) error {
 	return nil
 }
 
-func Remove(path string) (e error) {
+func Ensure(path string, perm os.FileMode) error { return EnsureWithFs(DefaultFs, path, perm) }
+
+func RemoveWithFs(fs Fs, path string) (e error) {
 	attempt := RemoveAttempts
 
 	for attempt > 0 {
-		e = os.RemoveAll(path)
+		e = fs.RemoveAll(path)
 		if e != nil {
 			attempt--
 			continue
@@ -104,11 +109,13 @@ func Remove(path string) (e error) {
 	return e
 }
 
-func MV(oldpath, newpath string) (e error) {
+func Remove(path string) (e error) { return RemoveWithFs(DefaultFs, path) }
+
+func MVWithFs(fs Fs, oldpath, newpath string) (e error) {
 	attempt := MvAttempts
 
 	for attempt > 0 {
-		e = os.Rename(oldpath, newpath)
+		e = fs.Rename(oldpath, newpath)
 		if e != nil {
 			attempt--
 			continue
@@ -120,8 +127,10 @@ func MV(oldpath, newpath string) (e error) {
 	return e
 }
 
-func IsDirEmpty(name string) (bool, error) {
-	f, e := os.Open(name)
+func MV(oldpath, newpath string) (e error) { return MVWithFs(DefaultFs, oldpath, newpath) }
+
+func IsDirEmptyWithFs(fs Fs, name string) (bool, error) {
+	f, e := fs.Open(name)
 	if e != nil {
 		return false, e
 	}
@@ -138,6 +147,8 @@ func IsDirEmpty(name string) (bool, error) {
 	return false, e
 }
 
+func IsDirEmpty(name string) (bool, error) { return IsDirEmptyWithFs(DefaultFs, name) }
+
 func Absolutize(paths []*string) error {
 	for _, p := range paths {
 		v, err := filepath.Abs(*p)
@@ -166,11 +177,7 @@ func NewPathAndModes() PathAndModes { return PathAndModes{} }
 
 func EnsureMany(paths PathAndModes) error {
 	for _, r := range paths {
-		if _, e := os.Stat(r.p); os.IsNotExist(e) {
-			if e = os.MkdirAll(r.p, r.mode); e != nil {
-				return e
-			}
-		} else if e != nil {
+		if e := Ensure(r.p, r.mode); e != nil {
 			return e
 		}
 	}
@@ -188,32 +195,34 @@ func AbsentMany(paths []string) (err error) {
 	return
 }
 
-func Clear(path string) (err error) {
-	if ok, err := IsExists(path); err != nil {
+func ClearWithFs(fs Fs, path string) (err error) {
+	if ok, err := IsExistsWithFs(fs, path); err != nil {
 		return err
 	} else if !ok {
 		return nil
 	}
 
-	fs, err := ioutil.ReadDir(path)
+	fis, err := fs.ReadDir(path)
 	if err != nil {
 		return err
 	}
 
-	for _, f := range fs {
-		err = Remove(filepath.Join(path, f.Name()))
+	for _, fi := range fis {
+		err = RemoveWithFs(fs, filepath.Join(path, fi.Name()))
 	}
 
 	return err
 }
 
-func ToFile(path string, mode os.FileMode, reader io.Reader) error {
+func Clear(path string) (err error) { return ClearWithFs(DefaultFs, path) }
+
+func ToFileWithFs(fs Fs, path string, mode os.FileMode, reader io.Reader) error {
 	dir := filepath.Dir(path)
-	if e := Ensure(dir, mode); e != nil {
+	if e := EnsureWithFs(fs, dir, mode); e != nil {
 		return e
 	}
 
-	f, e := os.Create(path)
+	f, e := fs.Create(path)
 	if e != nil {
 		return e
 	}
@@ -226,13 +235,17 @@ func ToFile(path string, mode os.FileMode, reader io.Reader) error {
 	return nil
 }
 
-func WriteFile(path string, dirMode, fileMode os.FileMode, src io.Reader) (int64, error) {
+func ToFile(path string, mode os.FileMode, reader io.Reader) error {
+	return ToFileWithFs(DefaultFs, path, mode, reader)
+}
+
+func WriteFileWithFs(fs Fs, path string, dirMode, fileMode os.FileMode, src io.Reader) (int64, error) {
 	dir := filepath.Dir(path)
-	if e := Ensure(dir, dirMode); e != nil {
+	if e := EnsureWithFs(fs, dir, dirMode); e != nil {
 		return 0, e
 	}
 
-	f, e := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	f, e := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
 	if e != nil {
 		return 0, e
 	}
@@ -246,23 +259,31 @@ func WriteFile(path string, dirMode, fileMode os.FileMode, src io.Reader) (int64
 	return sz, nil
 }
 
-func Rename(oldpath, newpath string, mode os.FileMode) error {
-	if e := Ensure(filepath.Dir(newpath), mode); e != nil {
+func WriteFile(path string, dirMode, fileMode os.FileMode, src io.Reader) (int64, error) {
+	return WriteFileWithFs(DefaultFs, path, dirMode, fileMode, src)
+}
+
+func RenameWithFs(fs Fs, oldpath, newpath string, mode os.FileMode) error {
+	if e := EnsureWithFs(fs, filepath.Dir(newpath), mode); e != nil {
 		return nil
 	}
 
-	return os.Rename(oldpath, newpath)
+	return fs.Rename(oldpath, newpath)
 }
 
-func CopyFile(source string, dest string, mode os.FileMode) error {
-	sourcefile, err := os.Open(source)
+func Rename(oldpath, newpath string, mode os.FileMode) error {
+	return RenameWithFs(DefaultFs, oldpath, newpath, mode)
+}
+
+func CopyFileWithFs(fs Fs, source string, dest string, mode os.FileMode) error {
+	sourcefile, err := fs.Open(source)
 	if err != nil {
 		return err
 	}
 
 	defer sourcefile.Close()
 
-	destfile, err := os.Create(dest)
+	destfile, err := fs.Create(dest)
 	if err != nil {
 		return err
 	}
@@ -271,15 +292,15 @@ func CopyFile(source string, dest string, mode os.FileMode) error {
 
 	if _, err := io.Copy(destfile, sourcefile); err == nil {
 		if mode != 0 { // custom mode
-			if err = os.Chmod(dest, mode); err != nil {
+			if err = fs.Chmod(dest, mode); err != nil {
 				return err
 			}
 		} else { // copy mode from source file
-			sourceinfo, err := os.Stat(source)
+			sourceinfo, err := fs.Stat(source)
 			if err != nil {
 				return err
 			}
-			if err = os.Chmod(dest, sourceinfo.Mode()); err != nil {
+			if err = fs.Chmod(dest, sourceinfo.Mode()); err != nil {
 				return err
 			}
 		}
@@ -288,23 +309,33 @@ func CopyFile(source string, dest string, mode os.FileMode) error {
 	return nil
 }
 
-func PathCopyDir(source string, dest string) (err error) {
+func CopyFile(source string, dest string, mode os.FileMode) error {
+	return CopyFileWithFs(DefaultFs, source, dest, mode)
+}
+
+func PathCopyDirWithFs(fs Fs, source string, dest string) (err error) {
 	// get properties of source dir
-	sourceinfo, err := os.Stat(source)
+	sourceinfo, err := fs.Stat(source)
 	if err != nil {
 		return err
 	}
 
 	// create dest dir
-
-	err = os.MkdirAll(dest, sourceinfo.Mode())
+	err = MkdirAllWithFs(fs, dest, sourceinfo.Mode())
 	if err != nil {
 		return err
 	}
 
-	directory, _ := os.Open(source)
+	directory, err := fs.Open(source)
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
 
 	objects, err := directory.Readdir(-1)
+	if err != nil {
+		return err
+	}
 
 	for _, obj := range objects {
 		sourcefilepointer := source + "/" + obj.Name()
@@ -313,13 +344,13 @@ func PathCopyDir(source string, dest string) (err error) {
 
 		if obj.IsDir() {
 			// create sub-directories - recursively
-			err = PathCopyDir(sourcefilepointer, destinationfilepointer)
+			err = PathCopyDirWithFs(fs, sourcefilepointer, destinationfilepointer)
 			if err != nil {
 				fmt.Println(err)
 			}
 		} else {
 			// perform copy
-			err = CopyFile(sourcefilepointer, destinationfilepointer, 0)
+			err = CopyFileWithFs(fs, sourcefilepointer, destinationfilepointer, 0)
 			if err != nil {
 				fmt.Println(err)
 			}
@@ -329,21 +360,29 @@ func PathCopyDir(source string, dest string) (err error) {
 	return
 }
 
-func CopyFileEnsureDir(src string, dst string, fileMode os.FileMode, dirMode os.FileMode) error {
+func PathCopyDir(source string, dest string) (err error) {
+	return PathCopyDirWithFs(DefaultFs, source, dest)
+}
+
+func CopyFileEnsureDirWithFs(fs Fs, src string, dst string, fileMode os.FileMode, dirMode os.FileMode) error {
 	dirPath := filepath.Dir(dst)
-	if e := Ensure(dirPath, dirMode); e != nil {
+	if e := EnsureWithFs(fs, dirPath, dirMode); e != nil {
 		return e
 	}
 
-	if e := CopyFile(src, dst, fileMode); e != nil {
+	if e := CopyFileWithFs(fs, src, dst, fileMode); e != nil {
 		return e
 	}
 
 	return nil
 }
 
-func RemoveFileOKEvenIfNotExists(path string) (e error) {
-	if e := os.Remove(path); e != nil {
+func CopyFileEnsureDir(src string, dst string, fileMode os.FileMode, dirMode os.FileMode) error {
+	return CopyFileEnsureDirWithFs(DefaultFs, src, dst, fileMode, dirMode)
+}
+
+func RemoveFileOKEvenIfNotExistsWithFs(fs Fs, path string) (e error) {
+	if e := fs.Remove(path); e != nil {
 		if pathErr, ok := e.(*os.PathError); ok {
 			if pathErr.Err.Error() == "no such file or directory" {
 				return nil
@@ -355,3 +394,7 @@ func RemoveFileOKEvenIfNotExists(path string) (e error) {
 
 	return nil
 }
+
+func RemoveFileOKEvenIfNotExists(path string) (e error) {
+	return RemoveFileOKEvenIfNotExistsWithFs(DefaultFs, path)
+}
@@ -0,0 +1,76 @@
+package fnspath
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// File abstracts the subset of *os.File operations used by this package,
+// so an Fs implementation can hand back something other than a real
+// file descriptor (e.g. an in-memory buffer).
+type File interface {
+	io.Reader
+	io.Writer
+	io.Closer
+
+	Name() string
+	Readdir(n int) ([]os.FileInfo, error)
+}
+
+// Fs abstracts the filesystem calls used throughout this package. The
+// package-level helpers (MkdirAll, CopyFile, WriteFile, ...) dispatch
+// through DefaultFs by default, but every one of them has a *WithFs
+// counterpart that accepts an Fs explicitly — useful for unit-testing
+// without touching disk, chroot-style sandboxing, or plugging in a
+// remote/virtual filesystem backend. The same design afero pioneered.
+type Fs interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Create(name string) (File, error)
+
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+
+	Mkdir(name string, perm os.FileMode) error
+	Remove(name string) error    // single file or empty directory, like os.Remove
+	RemoveAll(name string) error // recursive, like os.RemoveAll
+	Rename(oldname, newname string) error
+	Chmod(name string, mode os.FileMode) error
+
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// DefaultFs is the Fs used by every package-level helper that doesn't
+// take an Fs explicitly. It wraps the real OS filesystem.
+var DefaultFs Fs = NewOsFs()
+
+// OsFs is an Fs backed by the real operating system filesystem.
+type OsFs struct{}
+
+// NewOsFs returns an Fs backed by the real operating system filesystem.
+func NewOsFs() *OsFs { return &OsFs{} }
+
+func (*OsFs) Open(name string) (File, error) { return os.Open(name) }
+
+func (*OsFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (*OsFs) Create(name string) (File, error) { return os.Create(name) }
+
+func (*OsFs) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (*OsFs) Lstat(name string) (os.FileInfo, error) { return os.Lstat(name) }
+
+func (*OsFs) Mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
+
+func (*OsFs) Remove(name string) error { return os.Remove(name) }
+
+func (*OsFs) RemoveAll(name string) error { return os.RemoveAll(name) }
+
+func (*OsFs) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+
+func (*OsFs) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+
+func (*OsFs) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(name) }
@@ -0,0 +1,341 @@
+package fnspath
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFs is an in-memory Fs implementation, primarily intended for
+// unit-testing the helpers in this package without touching disk.
+// The zero value is not usable; use NewMemFs.
+type MemFs struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	name    string
+	dir     bool
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFs returns an empty in-memory Fs, rooted at "/".
+func NewMemFs() *MemFs {
+	return &MemFs{
+		nodes: map[string]*memNode{
+			"/": {name: "/", dir: true, mode: os.ModeDir | 0o755},
+		},
+	}
+}
+
+func memClean(name string) string {
+	return filepath.ToSlash(filepath.Clean("/" + name))
+}
+
+func (fs *MemFs) get(name string) (*memNode, bool) {
+	n, ok := fs.nodes[memClean(name)]
+	return n, ok
+}
+
+type memFileInfo struct{ n *memNode }
+
+func (fi memFileInfo) Name() string       { return filepath.Base(fi.n.name) }
+func (fi memFileInfo) Size() int64        { return int64(len(fi.n.data)) }
+func (fi memFileInfo) Mode() os.FileMode  { return fi.n.mode }
+func (fi memFileInfo) ModTime() time.Time { return fi.n.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.n.dir }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+func (fs *MemFs) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	n, ok := fs.get(name)
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return memFileInfo{n}, nil
+}
+
+// Lstat behaves like Stat: MemFs has no symlinks.
+func (fs *MemFs) Lstat(name string) (os.FileInfo, error) { return fs.Stat(name) }
+
+func (fs *MemFs) Mkdir(name string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := memClean(name)
+	if _, ok := fs.nodes[clean]; ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	parent := memClean(filepath.Dir(clean))
+	if p, ok := fs.nodes[parent]; !ok || !p.dir {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	fs.nodes[clean] = &memNode{name: clean, dir: true, mode: os.ModeDir | perm}
+
+	return nil
+}
+
+// Remove removes name if it's a file or an empty directory, like
+// os.Remove. Use RemoveAll for a recursive delete.
+func (fs *MemFs) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := memClean(name)
+
+	n, ok := fs.nodes[clean]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+
+	if n.dir {
+		prefix := clean + "/"
+		if clean == "/" {
+			prefix = clean
+		}
+		for k := range fs.nodes {
+			if k != clean && strings.HasPrefix(k, prefix) {
+				return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+			}
+		}
+	}
+
+	delete(fs.nodes, clean)
+
+	return nil
+}
+
+// RemoveAll removes name and, if it's a directory, everything beneath
+// it, like os.RemoveAll.
+func (fs *MemFs) RemoveAll(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := memClean(name)
+	prefix := clean + "/"
+	if clean == "/" {
+		prefix = clean
+	}
+
+	for k := range fs.nodes {
+		if k == clean || strings.HasPrefix(k, prefix) {
+			delete(fs.nodes, k)
+		}
+	}
+
+	return nil
+}
+
+// Rename moves oldname to newname, re-keying the whole subtree when
+// oldname is a directory, like os.Rename.
+func (fs *MemFs) Rename(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	oldClean := memClean(oldname)
+	newClean := memClean(newname)
+
+	if _, ok := fs.nodes[oldClean]; !ok {
+		return &os.PathError{Op: "rename", Path: oldname, Err: os.ErrNotExist}
+	}
+
+	if existing, ok := fs.nodes[newClean]; ok && existing.dir {
+		prefix := newClean + "/"
+		for k := range fs.nodes {
+			if k != newClean && strings.HasPrefix(k, prefix) {
+				return &os.PathError{Op: "rename", Path: newname, Err: errors.New("destination directory not empty")}
+			}
+		}
+	}
+
+	oldPrefix := oldClean + "/"
+
+	type move struct{ oldKey, newKey string }
+
+	var moves []move
+	for k := range fs.nodes {
+		if k == oldClean || strings.HasPrefix(k, oldPrefix) {
+			moves = append(moves, move{k, newClean + k[len(oldClean):]})
+		}
+	}
+
+	for _, m := range moves {
+		node := fs.nodes[m.oldKey]
+		node.name = m.newKey
+		delete(fs.nodes, m.oldKey)
+		fs.nodes[m.newKey] = node
+	}
+
+	return nil
+}
+
+func (fs *MemFs) Chmod(name string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	n, ok := fs.get(name)
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+
+	if n.dir {
+		n.mode = os.ModeDir | mode
+	} else {
+		n.mode = mode
+	}
+
+	return nil
+}
+
+func (fs *MemFs) ReadDir(name string) ([]os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := memClean(name)
+	if n, ok := fs.nodes[clean]; !ok || !n.dir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	prefix := clean
+	if prefix != "/" {
+		prefix += "/"
+	}
+
+	var infos []os.FileInfo
+	for k, n := range fs.nodes {
+		if k == clean || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if strings.Contains(k[len(prefix):], "/") {
+			continue // not a direct child
+		}
+		infos = append(infos, memFileInfo{n})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	return infos, nil
+}
+
+func (fs *MemFs) Open(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *MemFs) Create(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+}
+
+func (fs *MemFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := memClean(name)
+
+	n, ok := fs.nodes[clean]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+
+		n = &memNode{name: clean, mode: perm}
+		fs.nodes[clean] = n
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		n.data = nil
+	}
+
+	return &memFile{fs: fs, n: n, appendMode: flag&os.O_APPEND != 0}, nil
+}
+
+type memFile struct {
+	fs         *MemFs
+	n          *memNode
+	off        int
+	dirOff     int
+	appendMode bool
+}
+
+func (f *memFile) Name() string { return f.n.name }
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.off >= len(f.n.data) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.n.data[f.off:])
+	f.off += n
+
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.appendMode {
+		f.n.data = append(f.n.data, p...)
+		f.off = len(f.n.data)
+
+		return len(p), nil
+	}
+
+	end := f.off + len(p)
+	if end > len(f.n.data) {
+		grown := make([]byte, end)
+		copy(grown, f.n.data)
+		f.n.data = grown
+	}
+
+	copy(f.n.data[f.off:end], p)
+	f.off = end
+
+	return len(p), nil
+}
+
+func (f *memFile) Close() error { return nil }
+
+// Readdir matches *os.File.Readdir: each call returns the next n
+// entries (or all remaining when n <= 0), and returns io.EOF once
+// there are none left to return for an n > 0 call.
+func (f *memFile) Readdir(n int) ([]os.FileInfo, error) {
+	infos, err := f.fs.ReadDir(f.n.name)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := infos[f.dirOff:]
+
+	if n <= 0 {
+		f.dirOff = len(infos)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+
+	f.dirOff += n
+
+	return remaining[:n], nil
+}
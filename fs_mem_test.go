@@ -0,0 +1,78 @@
+package fnspath
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestMemFsWriteRead(t *testing.T) {
+	fs := NewMemFs()
+
+	if _, err := WriteFileWithFs(fs, "/a/b/c.txt", 0o755, 0o644, bytes.NewBufferString("hello")); err != nil {
+		t.Fatalf("WriteFileWithFs: %v", err)
+	}
+
+	f, err := fs.Open("/a/b/c.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if string(got) != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestMemFsIsDirEmpty(t *testing.T) {
+	fs := NewMemFs()
+
+	if err := MkdirAllWithFs(fs, "/empty", 0o755); err != nil {
+		t.Fatalf("MkdirAllWithFs: %v", err)
+	}
+
+	empty, err := IsDirEmptyWithFs(fs, "/empty")
+	if err != nil {
+		t.Fatalf("IsDirEmptyWithFs: %v", err)
+	}
+	if !empty {
+		t.Fatalf("expected /empty to be reported empty")
+	}
+
+	if err := ToFileWithFs(fs, "/empty/f.txt", 0o644, bytes.NewBufferString("x")); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+
+	empty, err = IsDirEmptyWithFs(fs, "/empty")
+	if err != nil {
+		t.Fatalf("IsDirEmptyWithFs: %v", err)
+	}
+	if empty {
+		t.Fatalf("expected /empty to no longer be reported empty")
+	}
+}
+
+func TestMemFsRenameMovesSubtree(t *testing.T) {
+	fs := NewMemFs()
+
+	if err := ToFileWithFs(fs, "/d/child.txt", 0o644, bytes.NewBufferString("x")); err != nil {
+		t.Fatalf("ToFileWithFs: %v", err)
+	}
+
+	if err := fs.Rename("/d", "/e"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if _, err := fs.Stat("/d"); err == nil {
+		t.Fatalf("expected /d to be gone after rename")
+	}
+
+	if _, err := fs.Stat("/e/child.txt"); err != nil {
+		t.Fatalf("expected /e/child.txt to exist after rename: %v", err)
+	}
+}
@@ -2,13 +2,13 @@ package fnspath
 
 import (
 	"crypto/md5"
-	"io/ioutil"
-	"os"
 	"time"
 
 	"github.com/go-x-pkg/bufpool"
 )
 
+// MD5 is kept for existing callers; new code should prefer Checksum
+// with MD5Algo, which streams the file instead of reading it whole.
 type MD5 struct {
 	Latency time.Duration // calculation latency
 	Sum     [md5.Size]byte
@@ -21,34 +21,18 @@ func (m5 *MD5) Release() {
 	m5.B = nil
 }
 
-func (m5 *MD5) Do(path string) error {
-	start := time.Now()
-
-	defer func() { m5.Latency = time.Since(start) }()
-
-	fi, err := os.Stat(path)
+func (m5 *MD5) DoWithFs(fs Fs, path string) error {
+	d, err := Checksum(path, MD5Algo, WithFs(fs), WithBuffer())
 	if err != nil {
 		return err
 	}
 
-	m5.Sz = uint64(fi.Size())
-
-	data, e := ioutil.ReadFile(path)
-	if e != nil {
-		return nil
-	}
-
-	m5.Sum = md5.Sum(data)
-
-	b := m5.B
-	if b == nil {
-		b = bufpool.NewBuf()
-	}
-
-	b.Reset()
-	b.Write(data)
-
-	m5.B = b
+	m5.Latency = d.Latency
+	m5.Sz = d.Sz
+	m5.B = d.B
+	copy(m5.Sum[:], d.Sum)
 
 	return nil
 }
+
+func (m5 *MD5) Do(path string) error { return m5.DoWithFs(DefaultFs, path) }
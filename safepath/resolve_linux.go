@@ -0,0 +1,267 @@
+//go:build linux
+
+package safepath
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openat2Supported caches whether the running kernel understands
+// openat2(2) with RESOLVE_BENEATH. The probe runs at most once per
+// process; -1 means "not probed yet", 0 means "no", 1 means "yes".
+var openat2Supported int32 = -1
+
+func probeOpenat2() bool {
+	if cached := atomic.LoadInt32(&openat2Supported); cached >= 0 {
+		return cached == 1
+	}
+
+	fd, err := unix.Openat2(unix.AT_FDCWD, ".", &unix.OpenHow{
+		Flags:   unix.O_RDONLY | unix.O_DIRECTORY,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	})
+
+	supported := int32(0)
+	if err == nil {
+		unix.Close(fd)
+		supported = 1
+	} else if err != unix.ENOSYS {
+		// openat2 exists but rejected this particular call (e.g. "."
+		// isn't beneath AT_FDCWD in some sandboxes); treat that as
+		// "supported", the real calls will surface their own errors.
+		supported = 1
+	}
+
+	atomic.StoreInt32(&openat2Supported, supported)
+
+	return supported == 1
+}
+
+// openBeneath opens the directory `rel` (cleaned, possibly "." or "/")
+// under root, guaranteeing the resolved path never escapes root. If
+// create is true, missing components are created with perm along the
+// way.
+func openBeneath(root, rel string, mode Mode, create bool, perm os.FileMode) (*os.File, error) {
+	useOpenat2 := mode == ModeOpenat2 || (mode == ModeAuto && probeOpenat2())
+
+	if useOpenat2 {
+		f, err := openBeneathOpenat2(root, rel, create, perm)
+		if err == nil {
+			return f, nil
+		}
+
+		if mode == ModeOpenat2 || !fallbackToManual(err) {
+			return nil, err
+		}
+
+		// Remember it (when it's ENOSYS, later calls skip the probe
+		// entirely) and fall through to the manual walk below.
+		if err == unix.ENOSYS {
+			atomic.StoreInt32(&openat2Supported, 0)
+		}
+	}
+
+	return openBeneathManual(root, rel, create, perm)
+}
+
+var openat2DirHow = unix.OpenHow{
+	Flags:   unix.O_RDONLY | unix.O_DIRECTORY,
+	Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+}
+
+// fallbackToManual reports whether err, returned from an openat2 call,
+// warrants retrying via the manual openat(O_NOFOLLOW) walk rather than
+// being surfaced to the caller: ENOSYS (no kernel support), or EXDEV/
+// EPERM (some sandboxes/seccomp filters reject openat2 outright even
+// though the syscall itself exists).
+func fallbackToManual(err error) bool {
+	return err == unix.ENOSYS || err == unix.EXDEV || err == unix.EPERM
+}
+
+// openBeneathOpenat2 walks rel component-by-component from root using
+// openat2(RESOLVE_BENEATH), the same way openBeneathManual walks with
+// plain openat. root itself is opened with a plain os.Open: openat2
+// rejects an absolute pathname under RESOLVE_BENEATH (EXDEV), so
+// RESOLVE_BENEATH only applies to the per-component walk beneath the
+// resulting fd. Components are opened one at a time (rather than
+// resolving the whole rel in a single openat2 call) so that, when
+// create is true, a missing component can be mkdirat'd under the
+// already-confined parent fd and the walk can continue.
+func openBeneathOpenat2(root, rel string, create bool, perm os.FileMode) (*os.File, error) {
+	dir, err := os.Open(root)
+	if err != nil {
+		return nil, err
+	}
+
+	rel = strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return dir, nil
+	}
+
+	parts := strings.Split(rel, "/")
+
+	for _, part := range parts {
+		if part == "" || part == "." {
+			continue
+		}
+
+		fd, err := unix.Openat2(int(dir.Fd()), part, &openat2DirHow)
+		if err != nil {
+			if err == unix.ENOENT && create {
+				if merr := mkdirat(dir, part, perm); merr != nil && !os.IsExist(merr) {
+					dir.Close()
+					return nil, merr
+				}
+
+				fd, err = unix.Openat2(int(dir.Fd()), part, &openat2DirHow)
+			}
+
+			if err != nil {
+				dir.Close()
+				return nil, err
+			}
+		}
+
+		dir.Close()
+		dir = os.NewFile(uintptr(fd), "")
+	}
+
+	return dir, nil
+}
+
+// openBeneathManual walks rel component-by-component from root,
+// opening each one with O_NOFOLLOW so a symlink planted anywhere in
+// the path is rejected instead of followed. Used when openat2 isn't
+// available (ENOSYS), when ModeAuto sees it refused with EXDEV/EPERM
+// (see fallbackToManual), or when ModeOpenat is requested explicitly.
+func openBeneathManual(root, rel string, create bool, perm os.FileMode) (*os.File, error) {
+	dir, err := os.Open(root)
+	if err != nil {
+		return nil, err
+	}
+
+	rel = strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return dir, nil
+	}
+
+	parts := strings.Split(rel, "/")
+
+	for _, part := range parts {
+		if part == "" || part == "." {
+			continue
+		}
+		if part == ".." {
+			dir.Close()
+			return nil, os.ErrPermission
+		}
+
+		fi, lerr := lstatat(dir, part)
+		if lerr != nil {
+			if os.IsNotExist(lerr) && create {
+				if merr := mkdirat(dir, part, perm); merr != nil && !os.IsExist(merr) {
+					dir.Close()
+					return nil, merr
+				}
+			} else if lerr != nil {
+				dir.Close()
+				return nil, lerr
+			}
+		} else if fi.Mode()&os.ModeSymlink != 0 {
+			dir.Close()
+			return nil, os.ErrPermission
+		}
+
+		next, oerr := openatDir(dir, part)
+		dir.Close()
+		if oerr != nil {
+			return nil, oerr
+		}
+
+		dir = next
+	}
+
+	return dir, nil
+}
+
+func lstatat(dir *os.File, name string) (os.FileInfo, error) {
+	var st unix.Stat_t
+	if err := unix.Fstatat(int(dir.Fd()), name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: name, Err: err}
+	}
+
+	return statInfo{name: name, st: st}, nil
+}
+
+type statInfo struct {
+	name string
+	st   unix.Stat_t
+}
+
+func (s statInfo) Name() string { return s.name }
+func (s statInfo) Size() int64  { return s.st.Size }
+func (s statInfo) Mode() os.FileMode {
+	return os.FileMode(s.st.Mode&0o777) | modeFromType(s.st.Mode)
+}
+func (s statInfo) ModTime() time.Time { return time.Unix(s.st.Mtim.Sec, s.st.Mtim.Nsec) }
+func (s statInfo) IsDir() bool        { return s.st.Mode&unix.S_IFMT == unix.S_IFDIR }
+func (s statInfo) Sys() interface{}   { return &s.st }
+
+func modeFromType(m uint32) os.FileMode {
+	switch m & unix.S_IFMT {
+	case unix.S_IFDIR:
+		return os.ModeDir
+	case unix.S_IFLNK:
+		return os.ModeSymlink
+	default:
+		return 0
+	}
+}
+
+func openatDir(dir *os.File, name string) (*os.File, error) {
+	fd, err := unix.Openat(int(dir.Fd()), name, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+
+	return os.NewFile(uintptr(fd), ""), nil
+}
+
+func openatFile(dir *os.File, name string, flag int, perm os.FileMode) (*os.File, error) {
+	fd, err := unix.Openat(int(dir.Fd()), name, flag|unix.O_NOFOLLOW, uint32(perm))
+	if err != nil {
+		return nil, &os.PathError{Op: "openat", Path: name, Err: err}
+	}
+
+	return os.NewFile(uintptr(fd), ""), nil
+}
+
+func mkdirat(dir *os.File, name string, perm os.FileMode) error {
+	if err := unix.Mkdirat(int(dir.Fd()), name, uint32(perm)); err != nil {
+		return &os.PathError{Op: "mkdirat", Path: name, Err: err}
+	}
+
+	return nil
+}
+
+func unlinkat(dir *os.File, name string) error {
+	if err := unix.Unlinkat(int(dir.Fd()), name, 0); err != nil {
+		if err == unix.EISDIR {
+			if rerr := unix.Unlinkat(int(dir.Fd()), name, unix.AT_REMOVEDIR); rerr != nil {
+				return &os.PathError{Op: "unlinkat", Path: name, Err: rerr}
+			}
+
+			return nil
+		}
+
+		return &os.PathError{Op: "unlinkat", Path: name, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package safepath
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrUnsupported is returned on platforms where the symlink-safe walk
+// (openat2/openat) hasn't been implemented yet.
+var ErrUnsupported = errors.New("safepath: not implemented on this platform")
+
+func openBeneath(root, rel string, mode Mode, create bool, perm os.FileMode) (*os.File, error) {
+	return nil, ErrUnsupported
+}
+
+func mkdirat(dir *os.File, name string, perm os.FileMode) error { return ErrUnsupported }
+
+func openatFile(dir *os.File, name string, flag int, perm os.FileMode) (*os.File, error) {
+	return nil, ErrUnsupported
+}
+
+func unlinkat(dir *os.File, name string) error { return ErrUnsupported }
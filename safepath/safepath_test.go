@@ -0,0 +1,92 @@
+//go:build linux
+
+package safepath
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeMkdirAllCreatesNestedDirs(t *testing.T) {
+	root := t.TempDir()
+
+	if err := SafeMkdirAll(root, "a/b/c", 0o755, Config{}); err != nil {
+		t.Fatalf("SafeMkdirAll: %v", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(root, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !fi.IsDir() {
+		t.Fatalf("expected a/b/c to be a directory")
+	}
+}
+
+func TestSafeMkdirAllRejectsEscape(t *testing.T) {
+	root := t.TempDir()
+
+	if err := SafeMkdirAll(root, "../escape", 0o755, Config{}); err == nil {
+		t.Fatalf("expected SafeMkdirAll to reject a path escaping root")
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escape")); err == nil {
+		t.Fatalf("escape directory should not have been created outside root")
+	}
+}
+
+func TestSafeMkdirAllRejectsSymlinkEscape(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	if err := SafeMkdirAll(root, "link/nested", 0o755, Config{}); err == nil {
+		t.Fatalf("expected SafeMkdirAll to refuse to follow a symlink out of root")
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "nested")); err == nil {
+		t.Fatalf("nested directory should not have been created through the symlink")
+	}
+}
+
+func TestSafeCopyFileRoundTrip(t *testing.T) {
+	srcRoot := t.TempDir()
+	dstRoot := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(srcRoot, "in.txt"), []byte("payload"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := SafeCopyFile(srcRoot, "in.txt", dstRoot, "nested/out.txt", 0o644, Config{}); err != nil {
+		t.Fatalf("SafeCopyFile: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dstRoot, "nested", "out.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, []byte("payload")) {
+		t.Fatalf("got %q, want %q", got, "payload")
+	}
+}
+
+func TestSafeRemove(t *testing.T) {
+	root := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(root, "victim.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := SafeRemove(root, "victim.txt", Config{}); err != nil {
+		t.Fatalf("SafeRemove: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "victim.txt")); err == nil {
+		t.Fatalf("expected victim.txt to be removed")
+	}
+}